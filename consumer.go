@@ -1,9 +1,9 @@
 package rabbitmq
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
-	"os"
 	"sync"
 	"time"
 
@@ -30,6 +30,32 @@ type Options struct {
 	// ReopenChannelWait sets the duration to wait after a failed attempt to open a
 	// channel on a RabbitMQ connection.
 	ReopenChannelWait time.Duration
+
+	// Topology declares the channel's exchanges, queues, bindings and QoS.
+	// It is invoked on the underlying channel immediately after it is
+	// (re-)opened, both on the initial connect and after every reconnect, so
+	// that topology survives broker restarts.
+	Topology func(ch *amqp.Channel) error
+
+	// Logger receives structured log events. Defaults to a logger writing
+	// to os.Stdout.
+	Logger Logger
+
+	// StateObserver, if set, is called whenever the consumer's Status
+	// transitions.
+	StateObserver StateObserver
+
+	// AMQPConfig, if set, overrides the amqp.Config used to dial RabbitMQ,
+	// e.g. to set a custom Heartbeat, Locale, SASL mechanism or Vhost.
+	// Defaults to the amqp091-go library defaults.
+	AMQPConfig *amqp.Config
+
+	// TLSConfig, if set, is used as the TLS client configuration when
+	// dialing an amqps:// URI, e.g. to present a client certificate for
+	// mTLS against a managed RabbitMQ offering. It overrides any
+	// TLSClientConfig set on AMQPConfig. It does not switch an amqp:// URI
+	// to TLS.
+	TLSConfig *tls.Config
 }
 
 // An Option configures consumer options.
@@ -61,44 +87,183 @@ func WithReopenChannelWait(wait time.Duration) Option {
 	}
 }
 
-type status int
+// WithTopology sets the function used to declare exchanges, queues,
+// bindings (including any DLX/DLQ) and QoS on the channel. It runs
+// immediately after the channel is (re-)opened, both on the initial connect
+// and after every reconnect, so the topology is always in place before
+// Consume is called.
+func WithTopology(topology func(ch *amqp.Channel) error) Option {
+	return func(o *Options) {
+		o.Topology = topology
+	}
+}
 
-func (s status) String() string {
+// WithLogger sets the Logger used to report connection lifecycle events.
+func WithLogger(logger Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithStateObserver sets a callback that is invoked whenever the consumer's
+// Status transitions, e.g. to observe reconnects or drive a
+// "rabbitmq_connected" metric.
+func WithStateObserver(observer StateObserver) Option {
+	return func(o *Options) {
+		o.StateObserver = observer
+	}
+}
+
+// WithAMQPConfig overrides the amqp.Config used to dial RabbitMQ, e.g. to
+// set a custom Heartbeat, Locale, SASL mechanism or Vhost. See amqp.Config
+// for the available fields.
+func WithAMQPConfig(cfg amqp.Config) Option {
+	return func(o *Options) {
+		o.AMQPConfig = &cfg
+	}
+}
+
+// WithTLSConfig sets cfg as the TLS client configuration used when dialing
+// an amqps:// URI. This is required to connect with a client certificate,
+// e.g. against managed RabbitMQ offerings that require mTLS.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *Options) {
+		o.TLSConfig = cfg
+	}
+}
+
+// A Status is a snapshot of a Consumer's or Publisher's connection state.
+type Status int
+
+func (s Status) String() string {
 	switch s {
-	case disconnected:
+	case StatusDisconnected:
 		return "disconnected"
-	case connecting:
+	case StatusConnecting:
 		return "connecting"
-	case connected:
+	case StatusConnected:
 		return "connected"
-	case reconnecting:
+	case StatusReconnecting:
 		return "reconnecting"
-	case closed:
+	case StatusClosed:
 		return "closed"
 	}
 	return "unknown"
 }
 
 const (
-	disconnected = status(iota)
-	connecting
-	connected
-	reconnecting
-	closed
+	StatusDisconnected = Status(iota)
+	StatusConnecting
+	StatusConnected
+	StatusReconnecting
+	StatusClosed
 )
 
+// subscription records everything needed to re-register a consumer with the
+// broker after a reconnect.
+type subscription struct {
+	queue   string
+	receive func(d amqp.Delivery)
+	opts    ConsumeOptions
+}
+
+// ConsumeOptions customizes a single subscription registered via
+// ConsumeWithOptions.
+type ConsumeOptions struct {
+	// PrefetchCount sets how many un-acked deliveries the broker will
+	// dispatch before waiting for acks.
+	PrefetchCount int
+
+	// PrefetchSize sets the prefetch window in octets.
+	PrefetchSize int
+
+	// AutoAck, when true, acks deliveries as soon as they are sent rather
+	// than waiting for the delivery to be acked in receive.
+	AutoAck bool
+
+	// Exclusive, when true, asks the broker to restrict the queue to this
+	// consumer only.
+	Exclusive bool
+
+	// Args are additional arguments passed to the broker when registering
+	// the consumer.
+	Args amqp.Table
+
+	// Concurrency sets how many goroutines drain the delivery channel and
+	// call receive concurrently. Defaults to 1.
+	Concurrency int
+}
+
+// A ConsumeOption configures a ConsumeOptions.
+type ConsumeOption func(*ConsumeOptions)
+
+// WithPrefetchCount sets how many un-acked deliveries the broker will
+// dispatch to the consumer before waiting for acks.
+func WithPrefetchCount(count int) ConsumeOption {
+	return func(o *ConsumeOptions) {
+		o.PrefetchCount = count
+	}
+}
+
+// WithPrefetchSize sets the prefetch window, in octets, for the consumer.
+func WithPrefetchSize(size int) ConsumeOption {
+	return func(o *ConsumeOptions) {
+		o.PrefetchSize = size
+	}
+}
+
+// WithAutoAck makes the consumer auto-ack deliveries as soon as they are
+// sent by the broker.
+func WithAutoAck() ConsumeOption {
+	return func(o *ConsumeOptions) {
+		o.AutoAck = true
+	}
+}
+
+// WithExclusiveConsumer restricts the queue to this consumer only.
+func WithExclusiveConsumer() ConsumeOption {
+	return func(o *ConsumeOptions) {
+		o.Exclusive = true
+	}
+}
+
+// WithConsumeArgs sets additional arguments passed to the broker when
+// registering the consumer.
+func WithConsumeArgs(args amqp.Table) ConsumeOption {
+	return func(o *ConsumeOptions) {
+		o.Args = args
+	}
+}
+
+// WithConcurrency sets how many goroutines drain the delivery channel and
+// call receive concurrently. Defaults to 1.
+func WithConcurrency(concurrency int) ConsumeOption {
+	return func(o *ConsumeOptions) {
+		o.Concurrency = concurrency
+	}
+}
+
 type Consumer struct {
 	mu sync.RWMutex
 
 	opts   *Options
-	logger *log.Logger
+	logger Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	conn            *amqp.Connection
 	channel         *amqp.Channel
-	done            chan struct{}
-	status          status
+	status          Status
 	notifyConnClose chan *amqp.Error
 	notifyChanClose chan *amqp.Error
+
+	// wg tracks the worker goroutines draining active subscriptions, so
+	// Close can wait for in-flight receive callbacks to return.
+	wg sync.WaitGroup
+
+	subsMu        sync.Mutex
+	subscriptions map[string]subscription
 }
 
 // NewConsumer creates a Consumer that synchronously connects/opens a
@@ -106,7 +271,9 @@ type Consumer struct {
 // channel it will automatically re-connect and re-open connection and channel
 // if they fail. A consumer holds on to one connection and one channel.
 // A consumer can be used to consume multiple times and from multiple goroutines.
-func NewConsumer(URI string, options ...Option) (*Consumer, error) {
+// ctx bounds the consumer's lifetime: canceling it stops the reconnect loop
+// and any pending reconnect wait as promptly as calling Close.
+func NewConsumer(ctx context.Context, URI string, options ...Option) (*Consumer, error) {
 	opts := &Options{
 		ReconnectWait:     DefaultReconnectWait,
 		ReopenChannelWait: DefaultReopenChannelWait,
@@ -119,11 +286,19 @@ func NewConsumer(URI string, options ...Option) (*Consumer, error) {
 		return nil, err
 	}
 
+	logger := opts.Logger
+	if logger == nil {
+		logger = newDefaultLogger()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
 	c := Consumer{
-		opts:   opts,
-		logger: log.New(os.Stdout, "", log.LstdFlags),
-		done:   make(chan struct{}),
-		status: disconnected,
+		opts:          opts,
+		logger:        logger,
+		ctx:           ctx,
+		cancel:        cancel,
+		status:        StatusDisconnected,
+		subscriptions: make(map[string]subscription),
 	}
 
 	err = c.createConnection(URI)
@@ -134,6 +309,10 @@ func NewConsumer(URI string, options ...Option) (*Consumer, error) {
 	if err != nil {
 		return nil, err
 	}
+	err = c.applyTopology()
+	if err != nil {
+		return nil, err
+	}
 
 	go c.maintainConnection(URI)
 
@@ -148,18 +327,43 @@ func validateOptions(opts *Options) error {
 	return nil
 }
 
+// setStatus updates the consumer's status and, if it actually changed,
+// notifies the configured StateObserver of the transition. err is passed
+// through to the observer and should describe why the transition happened,
+// if anything went wrong. Callers must hold c.mu.
+//
+// StateObserver is invoked synchronously while c.mu is held for writing, so
+// it must not call back into the Consumer (Consume, Cancel, Close, or even a
+// status read) — doing so will deadlock. Keep observers limited to cheap,
+// self-contained work like updating a metric.
+func (c *Consumer) setStatus(new Status, err error) {
+	old := c.status
+	c.status = new
+	if old != new && c.opts.StateObserver != nil {
+		c.opts.StateObserver(old, new, err)
+	}
+}
+
 // createConnection will create a new AMQP connection
 func (c *Consumer) createConnection(addr string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.status == connected || c.status == reconnecting {
-		c.status = reconnecting
+	if c.status == StatusConnected || c.status == StatusReconnecting {
+		c.setStatus(StatusReconnecting, nil)
 	} else {
-		c.status = connecting
+		c.setStatus(StatusConnecting, nil)
+	}
+
+	cfg := amqp.Config{}
+	if c.opts.AMQPConfig != nil {
+		cfg = *c.opts.AMQPConfig
+	}
+	if c.opts.TLSConfig != nil {
+		cfg.TLSClientConfig = c.opts.TLSConfig
 	}
 
-	conn, err := amqp.Dial(addr)
+	conn, err := amqp.DialConfig(addr, cfg)
 	if err != nil {
 		return err
 	}
@@ -176,10 +380,10 @@ func (c *Consumer) createChannel() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if c.status == connected || c.status == reconnecting {
-		c.status = reconnecting
+	if c.status == StatusConnected || c.status == StatusReconnecting {
+		c.setStatus(StatusReconnecting, nil)
 	} else {
-		c.status = connecting
+		c.setStatus(StatusConnecting, nil)
 	}
 
 	ch, err := c.conn.Channel()
@@ -190,7 +394,7 @@ func (c *Consumer) createChannel() error {
 
 	c.notifyChanClose = make(chan *amqp.Error, 1)
 	c.channel.NotifyClose(c.notifyChanClose)
-	c.status = connected
+	c.setStatus(StatusConnected, nil)
 
 	return nil
 }
@@ -200,55 +404,62 @@ func (c *Consumer) createChannel() error {
 // re-opening a channel on notifyChanClose events
 func (c *Consumer) maintainConnection(addr string) {
 	select {
-	case <-c.done:
-		c.logger.Println("Stopping connection loop due to done closed")
+	case <-c.ctx.Done():
+		c.logger.Info("stopping connection loop due to context canceled")
 		return
 	case <-c.notifyConnClose:
-		c.logger.Println("Connection closed. Re-connecting...")
+		c.logger.Warn("connection closed, re-connecting")
 
 		for {
 			err := c.createConnection(addr)
 			if err != nil {
-				c.logger.Println("Failed to connect. Retrying...")
+				c.logger.Warn("failed to connect, retrying", "error", err)
 				t := time.NewTimer(c.opts.ReconnectWait)
 				select {
-				case <-c.done:
+				case <-c.ctx.Done():
 					if !t.Stop() {
 						<-t.C
 					}
-					c.logger.Println("Stopping connection loop due to done closed")
+					c.logger.Info("stopping connection loop due to context canceled")
 					return
 				case <-t.C:
 				}
 				continue
 			}
-			c.logger.Println("Consumer connection re-established")
+			c.logger.Info("consumer connection re-established")
 
 			c.openChannel()
-			c.logger.Println("Consumer connection and channel re-established")
+			c.logger.Info("consumer connection and channel re-established")
 			break
 		}
 	case <-c.notifyChanClose:
-		c.logger.Println("Channel closed. Re-opening new one...")
+		c.logger.Warn("channel closed, re-opening new one")
 		c.openChannel()
-		c.logger.Println("Consumer channel re-established")
+		c.logger.Info("consumer channel re-established")
 	}
 
 	c.maintainConnection(addr)
 }
 
-// openChannel opens a channel. Assumes a connection is open.
+// openChannel opens a channel. Assumes a connection is open. Once the
+// channel is open, the topology is (re-)declared on it and every
+// subscription registered via Consume is re-registered against it so
+// active consumers survive a reconnect.
 func (c *Consumer) openChannel() {
 	for {
 		err := c.createChannel()
 		if err == nil {
+			err = c.applyTopology()
+		}
+		if err == nil {
+			c.resubscribeAll()
 			return
 		}
 
-		c.logger.Println("Failed to open channel. Retrying...")
+		c.logger.Warn("failed to open channel, retrying", "error", err)
 		t := time.NewTimer(c.opts.ReopenChannelWait)
 		select {
-		case <-c.done:
+		case <-c.ctx.Done():
 			if !t.Stop() {
 				<-t.C
 			}
@@ -258,6 +469,47 @@ func (c *Consumer) openChannel() {
 	}
 }
 
+// applyTopology invokes the configured Topology function on the channel, if
+// any. Assumes a channel is open.
+func (c *Consumer) applyTopology() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.opts.Topology == nil {
+		return nil
+	}
+
+	return c.opts.Topology(c.channel)
+}
+
+// resubscribeAll re-registers the consumer for every subscription still in
+// the registry. Assumes a channel is open. Holds c.mu for reading across the
+// whole pass, both because consumeQueue reads c.channel and because it must
+// not race Close: Close sets StatusClosed and cancels subscriptions under
+// c.mu.Lock() before waiting on c.wg, so checking c.status here under
+// c.mu.RLock() guarantees no worker is added to c.wg after that wait begins.
+func (c *Consumer) resubscribeAll() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.status == StatusClosed {
+		return
+	}
+
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+
+	for id, sub := range c.subscriptions {
+		ds, err := c.consumeQueue(sub.queue, id, sub.opts)
+		if err != nil {
+			c.logger.Error("failed to re-subscribe consumer", "consumer", id, "queue", sub.queue, "error", err)
+			continue
+		}
+
+		c.startWorkers(ds, sub.receive, sub.opts.Concurrency)
+	}
+}
+
 type tempError struct {
 	err string
 }
@@ -270,71 +522,119 @@ func (te tempError) Temporary() bool {
 	return true
 }
 
-// Consume registers the consumer to receive messages from given queue.
-// Consume synchronously declares and registers a consumer to the queue.
-// Once registered it will return the consumer tag and nil error.
-// receive will be called for every message. Pass the consumer tag to
-// Cancel() to stop consuming messages. Consume will not re-consume if the
-// connection or channel close even if they only close temporarily.
-// Consume can be called multiple times and from multiple goroutines.
-func (c *Consumer) Consume(queue string, receive func(d amqp.Delivery)) (string, error) {
+// Consume registers the consumer to receive messages from given queue,
+// using the default ConsumeOptions (a single worker goroutine, manual ack).
+// See ConsumeWithOptions for details.
+func (c *Consumer) Consume(ctx context.Context, queue string, receive func(d amqp.Delivery)) (string, error) {
+	return c.ConsumeWithOptions(ctx, queue, receive)
+}
+
+// ConsumeWithOptions registers the consumer to receive messages from given
+// queue. The queue is expected to already exist; declare it, along with any
+// exchanges and bindings it needs, via WithTopology. ConsumeWithOptions
+// synchronously applies the requested QoS and registers a consumer on the
+// queue. Once registered it will return the consumer tag and nil error.
+// receive will be called for every message, from Concurrency worker
+// goroutines (default 1). Pass the consumer tag to Cancel() to stop
+// consuming messages. If the connection or channel close, the subscription
+// is automatically re-registered, with the same options, once they are
+// re-established; in-flight messages delivered before the close are not
+// redelivered.
+// ConsumeWithOptions can be called multiple times and from multiple
+// goroutines.
+func (c *Consumer) ConsumeWithOptions(ctx context.Context, queue string, receive func(d amqp.Delivery), options ...ConsumeOption) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	opts := ConsumeOptions{Concurrency: 1}
+	for _, o := range options {
+		o(&opts)
+	}
+
+	// Held until the subscription is registered below (not just across
+	// consumeQueue): resubscribeAll also runs under c.mu.RLock(), and
+	// createChannel/Close need c.mu.Lock() to run, so releasing early here
+	// would let a reconnect's resubscribeAll pass over this subscription
+	// before it is registered, silently stranding it on the old channel.
 	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	if c.status != connected {
+	if c.status != StatusConnected {
 		status := c.status
-		c.mu.RUnlock()
-		if status == reconnecting {
+		if status == StatusReconnecting {
 			return "", tempError{err: "temporarily failed to consume: re-connecting with broker"}
 		}
 		return "", fmt.Errorf("failed to consume: connection is in %q state", status)
 	}
 
-	_, err := c.channel.QueueDeclare(
-		queue,
-		false, // Durable
-		false, // Delete when unused
-		false, // Exclusive
-		false, // No-wait
-		nil,   // Arguments
-	)
+	id := c.opts.ConsumerPrefix + uuid.NewString()
+	ds, err := c.consumeQueue(queue, id, opts)
 	if err != nil {
-		c.mu.RUnlock()
 		return "", err
 	}
-	id := c.opts.ConsumerPrefix + uuid.NewString()
-	ds, err := c.channel.Consume(
+
+	c.subsMu.Lock()
+	c.subscriptions[id] = subscription{queue: queue, receive: receive, opts: opts}
+	c.subsMu.Unlock()
+
+	c.startWorkers(ds, receive, opts.Concurrency)
+	return id, nil
+}
+
+// consumeQueue applies opts' QoS and registers a consumer with the given tag
+// on queue. The queue itself is expected to already exist, declared by
+// Topology. Assumes a channel is open and the caller holds at least a read
+// lock on c.mu.
+func (c *Consumer) consumeQueue(queue, tag string, opts ConsumeOptions) (<-chan amqp.Delivery, error) {
+	if err := c.channel.Qos(opts.PrefetchCount, opts.PrefetchSize, false); err != nil {
+		return nil, err
+	}
+
+	return c.channel.Consume(
 		queue,
-		id,    // Consumer
-		false, // Auto-Ack
-		false, // Exclusive
+		tag,
+		opts.AutoAck,
+		opts.Exclusive,
 		false, // No-local
 		false, // No-Wait
-		nil,   // Args
+		opts.Args,
 	)
-	if err != nil {
-		c.mu.RUnlock()
-		return "", err
-	}
-	c.mu.RUnlock()
+}
 
-	go func() {
-		for d := range ds {
-			receive(d)
-		}
-	}()
-	return id, nil
+// startWorkers spawns concurrency goroutines (at least 1) that each call
+// receive for every delivery on ds until the channel is closed. Each worker
+// is tracked in c.wg so Close can wait for in-flight receive callbacks.
+func (c *Consumer) startWorkers(ds <-chan amqp.Delivery, receive func(d amqp.Delivery), concurrency int) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	for i := 0; i < concurrency; i++ {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			for d := range ds {
+				receive(d)
+			}
+		}()
+	}
 }
 
 // Cancel consuming messages for given consumer. The consumer identifier is
-// returned by Consume().
+// returned by Consume(). Cancel removes the subscription so it is not
+// re-registered on a subsequent reconnect.
 // It is safe to call this method multiple times and in multiple goroutines.
 func (c *Consumer) Cancel(consumer string) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	if c.status != connected {
+	c.subsMu.Lock()
+	delete(c.subscriptions, consumer)
+	c.subsMu.Unlock()
+
+	if c.status != StatusConnected {
 		status := c.status
-		if status == reconnecting {
+		if status == StatusReconnecting {
 			return tempError{err: "temporarily failed to cancel: re-connecting with broker"}
 		}
 		return fmt.Errorf("failed to cancel: connection is in %q state", status)
@@ -343,18 +643,44 @@ func (c *Consumer) Cancel(consumer string) error {
 	return c.channel.Cancel(consumer, false)
 }
 
-// Close connection and channel. A new consumer needs to be
+// Close cancels every registered consumer, waits for in-flight receive
+// callbacks to finish (or for ctx to be done, whichever comes first), and
+// then closes the channel and connection. A new consumer needs to be
 // created in order to consume again after closing it.
 // It is safe to call this method multiple times and in multiple goroutines.
-func (c *Consumer) Close() error {
+func (c *Consumer) Close(ctx context.Context) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if c.status != closed {
-		c.status = closed
+	if c.status != StatusClosed {
+		c.setStatus(StatusClosed, nil)
 		// stop re-connecting/re-opening a channel
-		close(c.done)
+		c.cancel()
+	}
+
+	c.subsMu.Lock()
+	for id := range c.subscriptions {
+		if c.channel != nil && !c.channel.IsClosed() {
+			if err := c.channel.Cancel(id, false); err != nil {
+				c.logger.Warn("failed to cancel consumer while closing", "consumer", id, "error", err)
+			}
+		}
+		delete(c.subscriptions, id)
 	}
+	c.subsMu.Unlock()
+	c.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		c.logger.Warn("closing before in-flight receive callbacks finished", "error", ctx.Err())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	// nothing to close if we do not have an open connection and channel
 	var errCh error
@@ -0,0 +1,53 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// A Logger receives structured log events from a Consumer or Publisher.
+// Each method takes a message and alternating key/value pairs describing
+// the event, mirroring the style used by zap's SugaredLogger, zerolog and
+// slog, so any of those can be adapted to satisfy this interface.
+type Logger interface {
+	Debug(msg string, keyvals ...interface{})
+	Info(msg string, keyvals ...interface{})
+	Warn(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// defaultLogger is the Logger used when none is supplied via WithLogger or
+// WithPublisherLogger. It writes to os.Stdout, matching this module's
+// previous behavior.
+type defaultLogger struct {
+	*log.Logger
+}
+
+func newDefaultLogger() *defaultLogger {
+	return &defaultLogger{log.New(os.Stdout, "", log.LstdFlags)}
+}
+
+func (l *defaultLogger) Debug(msg string, keyvals ...interface{}) { l.log("DEBUG", msg, keyvals) }
+func (l *defaultLogger) Info(msg string, keyvals ...interface{})  { l.log("INFO", msg, keyvals) }
+func (l *defaultLogger) Warn(msg string, keyvals ...interface{})  { l.log("WARN", msg, keyvals) }
+func (l *defaultLogger) Error(msg string, keyvals ...interface{}) { l.log("ERROR", msg, keyvals) }
+
+func (l *defaultLogger) log(level, msg string, keyvals []interface{}) {
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		msg += fmt.Sprintf(" %v=%v", keyvals[i], keyvals[i+1])
+	}
+	l.Logger.Println(level, msg)
+}
+
+// A StateObserver is notified whenever a Consumer's or Publisher's Status
+// transitions, for example from StatusConnected to StatusReconnecting. The
+// Consumer and Publisher in this package pass a nil err for every
+// transition; a failed connect or channel open attempt leaves the Status
+// unchanged and is not reported to the observer.
+//
+// The observer is called synchronously while the Consumer's or Publisher's
+// internal lock is held for writing. It must not call back into the
+// Consumer/Publisher (e.g. Consume, Publish, Close, or reading its status) -
+// doing so deadlocks.
+type StateObserver func(old, new Status, err error)
@@ -0,0 +1,605 @@
+package rabbitmq
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// returnDrainAttempts bounds how many scheduler yields publishWithConfirm
+// waits, after receiving an ack, for a concurrently in-flight return to
+// reach the returned channel.
+const returnDrainAttempts = 8
+
+// A PublisherOptions customizes the publisher.
+type PublisherOptions struct {
+	// ReconnectWait sets the duration to wait after a failed attempt to connect to
+	// RabbitMQ.
+	ReconnectWait time.Duration
+
+	// ReopenChannelWait sets the duration to wait after a failed attempt to open a
+	// channel on a RabbitMQ connection.
+	ReopenChannelWait time.Duration
+
+	// MaxPublishAttempts sets the maximum number of attempts, including the
+	// first, that Publish/PublishWithConfirm make for a publish that fails
+	// with a temporary (reconnecting) error. A value of 1 disables retrying.
+	MaxPublishAttempts int
+
+	// PublishRetryBackoff sets the duration to wait between publish retry
+	// attempts.
+	PublishRetryBackoff time.Duration
+
+	// Logger receives structured log events. Defaults to a logger writing
+	// to os.Stdout.
+	Logger Logger
+
+	// StateObserver, if set, is called whenever the publisher's Status
+	// transitions.
+	StateObserver StateObserver
+
+	// AMQPConfig, if set, overrides the amqp.Config used to dial RabbitMQ,
+	// e.g. to set a custom Heartbeat, Locale, SASL mechanism or Vhost.
+	// Defaults to the amqp091-go library defaults.
+	AMQPConfig *amqp.Config
+
+	// TLSConfig, if set, is used as the TLS client configuration when
+	// dialing an amqps:// URI, e.g. to present a client certificate for
+	// mTLS against a managed RabbitMQ offering. It overrides any
+	// TLSClientConfig set on AMQPConfig. It does not switch an amqp:// URI
+	// to TLS.
+	TLSConfig *tls.Config
+}
+
+// A PublisherOption configures publisher options.
+type PublisherOption func(*PublisherOptions)
+
+// WithPublisherReconnectWait sets the duration to wait after a failed attempt
+// to connect to RabbitMQ.
+func WithPublisherReconnectWait(wait time.Duration) PublisherOption {
+	return func(o *PublisherOptions) {
+		o.ReconnectWait = wait
+	}
+}
+
+// WithPublisherReopenChannelWait sets the duration to wait after a failed
+// attempt to open a channel on a RabbitMQ connection.
+func WithPublisherReopenChannelWait(wait time.Duration) PublisherOption {
+	return func(o *PublisherOptions) {
+		o.ReopenChannelWait = wait
+	}
+}
+
+// WithPublishRetry makes Publish and PublishWithConfirm transparently retry,
+// making up to maxAttempts attempts in total and waiting backoff between
+// attempts, whenever the publisher is re-connecting with the broker.
+func WithPublishRetry(maxAttempts int, backoff time.Duration) PublisherOption {
+	return func(o *PublisherOptions) {
+		o.MaxPublishAttempts = maxAttempts
+		o.PublishRetryBackoff = backoff
+	}
+}
+
+// WithPublisherLogger sets the Logger used to report connection lifecycle
+// events.
+func WithPublisherLogger(logger Logger) PublisherOption {
+	return func(o *PublisherOptions) {
+		o.Logger = logger
+	}
+}
+
+// WithPublisherStateObserver sets a callback that is invoked whenever the
+// publisher's Status transitions, e.g. to observe reconnects or drive a
+// "rabbitmq_connected" metric.
+func WithPublisherStateObserver(observer StateObserver) PublisherOption {
+	return func(o *PublisherOptions) {
+		o.StateObserver = observer
+	}
+}
+
+// WithPublisherAMQPConfig overrides the amqp.Config used to dial RabbitMQ,
+// e.g. to set a custom Heartbeat, Locale, SASL mechanism or Vhost. See
+// amqp.Config for the available fields.
+func WithPublisherAMQPConfig(cfg amqp.Config) PublisherOption {
+	return func(o *PublisherOptions) {
+		o.AMQPConfig = &cfg
+	}
+}
+
+// WithPublisherTLSConfig sets cfg as the TLS client configuration used when
+// dialing an amqps:// URI. This is required to connect with a client
+// certificate, e.g. against managed RabbitMQ offerings that require mTLS.
+func WithPublisherTLSConfig(cfg *tls.Config) PublisherOption {
+	return func(o *PublisherOptions) {
+		o.TLSConfig = cfg
+	}
+}
+
+// Publisher publishes messages to RabbitMQ over a connection and channel
+// that are automatically re-established on failure, mirroring Consumer.
+type Publisher struct {
+	mu sync.RWMutex
+
+	opts   *PublisherOptions
+	logger Logger
+
+	conn            *amqp.Connection
+	channel         *amqp.Channel
+	done            chan struct{}
+	status          Status
+	notifyConnClose chan *amqp.Error
+	notifyChanClose chan *amqp.Error
+
+	confirmMode   bool
+	notifyPublish chan amqp.Confirmation
+	notifyReturn  chan amqp.Return
+
+	returnsMu sync.Mutex
+	returns   map[string]chan amqp.Return
+
+	// confirmsMu guards confirms and also serializes GetNextPublishSeqNo
+	// with the matching PublishWithContext call, so the DeliveryTag a
+	// waiter is registered under is the one the broker will actually
+	// confirm.
+	confirmsMu sync.Mutex
+	confirms   map[uint64]chan amqp.Confirmation
+}
+
+// NewPublisher creates a Publisher that synchronously connects/opens a
+// channel to RabbitMQ at the given URI. If the publisher was able to
+// connect/open a channel it will automatically re-connect and re-open the
+// connection and channel if they fail. A publisher holds on to one
+// connection and one channel. A publisher can be used to publish multiple
+// times and from multiple goroutines.
+func NewPublisher(URI string, options ...PublisherOption) (*Publisher, error) {
+	opts := &PublisherOptions{
+		ReconnectWait:      DefaultReconnectWait,
+		ReopenChannelWait:  DefaultReopenChannelWait,
+		MaxPublishAttempts: 1,
+	}
+	for _, o := range options {
+		o(opts)
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = newDefaultLogger()
+	}
+
+	p := Publisher{
+		opts:     opts,
+		logger:   logger,
+		done:     make(chan struct{}),
+		status:   StatusDisconnected,
+		returns:  make(map[string]chan amqp.Return),
+		confirms: make(map[uint64]chan amqp.Confirmation),
+	}
+
+	err := p.createConnection(URI)
+	if err != nil {
+		return nil, err
+	}
+	err = p.createChannel()
+	if err != nil {
+		return nil, err
+	}
+
+	go p.maintainConnection(URI)
+
+	return &p, nil
+}
+
+// setStatus updates the publisher's status and, if it actually changed,
+// notifies the configured StateObserver of the transition. err is passed
+// through to the observer and should describe why the transition happened,
+// if anything went wrong. Callers must hold p.mu.
+//
+// StateObserver is invoked synchronously while p.mu is held for writing, so
+// it must not call back into the Publisher (Publish, PublishWithConfirm,
+// Close, or even a status read) — doing so will deadlock. Keep observers
+// limited to cheap, self-contained work like updating a metric.
+func (p *Publisher) setStatus(new Status, err error) {
+	old := p.status
+	p.status = new
+	if old != new && p.opts.StateObserver != nil {
+		p.opts.StateObserver(old, new, err)
+	}
+}
+
+// createConnection will create a new AMQP connection
+func (p *Publisher) createConnection(addr string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.status == StatusConnected || p.status == StatusReconnecting {
+		p.setStatus(StatusReconnecting, nil)
+	} else {
+		p.setStatus(StatusConnecting, nil)
+	}
+
+	cfg := amqp.Config{}
+	if p.opts.AMQPConfig != nil {
+		cfg = *p.opts.AMQPConfig
+	}
+	if p.opts.TLSConfig != nil {
+		cfg.TLSClientConfig = p.opts.TLSConfig
+	}
+
+	conn, err := amqp.DialConfig(addr, cfg)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+
+	p.notifyConnClose = make(chan *amqp.Error)
+	p.conn.NotifyClose(p.notifyConnClose)
+
+	return nil
+}
+
+// createChannel will open a channel. Assumes a connection is open. If the
+// publisher was previously put into confirm mode, it is put back into
+// confirm mode and the publish/return listeners are re-registered.
+func (p *Publisher) createChannel() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.status == StatusConnected || p.status == StatusReconnecting {
+		p.setStatus(StatusReconnecting, nil)
+	} else {
+		p.setStatus(StatusConnecting, nil)
+	}
+
+	ch, err := p.conn.Channel()
+	if err != nil {
+		return err
+	}
+	p.channel = ch
+
+	p.notifyChanClose = make(chan *amqp.Error, 1)
+	p.channel.NotifyClose(p.notifyChanClose)
+	p.notifyReturn = make(chan amqp.Return, 1)
+	p.channel.NotifyReturn(p.notifyReturn)
+	go p.dispatchReturns(p.notifyReturn)
+
+	if p.confirmMode {
+		if err := p.channel.Confirm(false); err != nil {
+			return err
+		}
+		p.notifyPublish = make(chan amqp.Confirmation, 1)
+		p.channel.NotifyPublish(p.notifyPublish)
+		go p.dispatchConfirms(p.notifyPublish)
+	}
+
+	p.setStatus(StatusConnected, nil)
+
+	return nil
+}
+
+// maintainConnection ensures the publisher's AMQP connection and channel are
+// both open, re-connecting on notifyConnClose events and re-opening a
+// channel on notifyChanClose events.
+func (p *Publisher) maintainConnection(addr string) {
+	select {
+	case <-p.done:
+		p.logger.Info("stopping connection loop due to done closed")
+		return
+	case <-p.notifyConnClose:
+		p.logger.Warn("connection closed, re-connecting")
+
+		for {
+			err := p.createConnection(addr)
+			if err != nil {
+				p.logger.Warn("failed to connect, retrying", "error", err)
+				t := time.NewTimer(p.opts.ReconnectWait)
+				select {
+				case <-p.done:
+					if !t.Stop() {
+						<-t.C
+					}
+					p.logger.Info("stopping connection loop due to done closed")
+					return
+				case <-t.C:
+				}
+				continue
+			}
+			p.logger.Info("publisher connection re-established")
+
+			p.openChannel()
+			p.logger.Info("publisher connection and channel re-established")
+			break
+		}
+	case <-p.notifyChanClose:
+		p.logger.Warn("channel closed, re-opening new one")
+		p.openChannel()
+		p.logger.Info("publisher channel re-established")
+	}
+
+	p.maintainConnection(addr)
+}
+
+// openChannel opens a channel. Assumes a connection is open.
+func (p *Publisher) openChannel() {
+	for {
+		err := p.createChannel()
+		if err == nil {
+			return
+		}
+
+		p.logger.Warn("failed to open channel, retrying", "error", err)
+		t := time.NewTimer(p.opts.ReopenChannelWait)
+		select {
+		case <-p.done:
+			if !t.Stop() {
+				<-t.C
+			}
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// dispatchReturns delivers NotifyReturn events to whichever in-flight
+// PublishWithConfirm call is waiting on the matching correlation ID, falling
+// back to a log entry so an unroutable mandatory publish is never silently
+// dropped.
+func (p *Publisher) dispatchReturns(returns chan amqp.Return) {
+	for r := range returns {
+		p.returnsMu.Lock()
+		ch, ok := p.returns[r.CorrelationId]
+		p.returnsMu.Unlock()
+
+		if !ok {
+			p.logger.Warn("message returned by broker (unroutable)", "exchange", r.Exchange, "routingKey", r.RoutingKey, "replyText", r.ReplyText)
+			continue
+		}
+		ch <- r
+	}
+}
+
+// dispatchConfirms delivers NotifyPublish confirmations to the per-publish
+// waiter registered under the matching DeliveryTag, so concurrent
+// PublishWithConfirm calls (and a single unroutable-but-acked mandatory
+// publish) never see one another's ack/nack. When the underlying channel
+// closes, every still-pending waiter is closed so a blocked caller observes
+// a temporary (reconnecting) error instead of hanging.
+func (p *Publisher) dispatchConfirms(confirms chan amqp.Confirmation) {
+	for c := range confirms {
+		p.confirmsMu.Lock()
+		ch, ok := p.confirms[c.DeliveryTag]
+		if ok {
+			delete(p.confirms, c.DeliveryTag)
+		}
+		p.confirmsMu.Unlock()
+
+		if !ok {
+			p.logger.Warn("received confirmation for unknown delivery tag", "deliveryTag", c.DeliveryTag)
+			continue
+		}
+		ch <- c
+	}
+
+	p.confirmsMu.Lock()
+	pending := p.confirms
+	p.confirms = make(map[uint64]chan amqp.Confirmation)
+	p.confirmsMu.Unlock()
+	for _, ch := range pending {
+		close(ch)
+	}
+}
+
+// Publish publishes msg to exchange with routingKey. It does not wait for
+// the broker to acknowledge receipt; use PublishWithConfirm for that.
+func (p *Publisher) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	return p.withRetry(ctx, func() error {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+
+		if p.status != StatusConnected {
+			return statusError(p.status)
+		}
+
+		return p.channel.PublishWithContext(ctx, exchange, routingKey, false, false, msg)
+	})
+}
+
+// PublishWithConfirm publishes msg to exchange with routingKey in publisher
+// confirm mode, publishing mandatory so the broker returns the message
+// instead of silently dropping it if it cannot be routed. It blocks until
+// the broker acks or nacks the message, the message is returned as
+// unroutable, or ctx is done.
+func (p *Publisher) PublishWithConfirm(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	return p.withRetry(ctx, func() error {
+		if err := p.enableConfirmMode(); err != nil {
+			return err
+		}
+		return p.publishWithConfirm(ctx, exchange, routingKey, msg)
+	})
+}
+
+func (p *Publisher) publishWithConfirm(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	if msg.CorrelationId == "" {
+		msg.CorrelationId = uuid.NewString()
+	}
+
+	returned := make(chan amqp.Return, 1)
+	p.returnsMu.Lock()
+	p.returns[msg.CorrelationId] = returned
+	p.returnsMu.Unlock()
+	defer func() {
+		p.returnsMu.Lock()
+		delete(p.returns, msg.CorrelationId)
+		p.returnsMu.Unlock()
+	}()
+
+	confirmed := make(chan amqp.Confirmation, 1)
+
+	p.mu.RLock()
+	if p.status != StatusConnected {
+		err := statusError(p.status)
+		p.mu.RUnlock()
+		return err
+	}
+
+	// GetNextPublishSeqNo and PublishWithContext must run atomically with
+	// respect to other publishes on this channel, otherwise the DeliveryTag
+	// we register confirmed under would not be the one the broker actually
+	// confirms for this message.
+	p.confirmsMu.Lock()
+	seqNo := p.channel.GetNextPublishSeqNo()
+	p.confirms[seqNo] = confirmed
+	err := p.channel.PublishWithContext(ctx, exchange, routingKey, true, false, msg)
+	p.confirmsMu.Unlock()
+	p.mu.RUnlock()
+	if err != nil {
+		p.confirmsMu.Lock()
+		delete(p.confirms, seqNo)
+		p.confirmsMu.Unlock()
+		return err
+	}
+	defer func() {
+		p.confirmsMu.Lock()
+		delete(p.confirms, seqNo)
+		p.confirmsMu.Unlock()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case r := <-returned:
+		return fmt.Errorf("message unroutable: exchange=%q routingKey=%q replyText=%q", r.Exchange, r.RoutingKey, r.ReplyText)
+	case confirm, ok := <-confirmed:
+		if !ok {
+			return tempError{err: "temporarily failed to confirm publish: re-connecting with broker"}
+		}
+		if !confirm.Ack {
+			return fmt.Errorf("message nacked by broker")
+		}
+
+		// For an unroutable mandatory publish the broker sends basic.return
+		// before basic.ack, but dispatchReturns and dispatchConfirms
+		// deliver them via independent goroutines, so a return already in
+		// flight may not yet have reached `returned` in this instant. Give
+		// it priority over the ack with a short, bounded, non-blocking
+		// drain instead of reporting success for a message that was never
+		// routed.
+		for i := 0; i < returnDrainAttempts; i++ {
+			select {
+			case r := <-returned:
+				return fmt.Errorf("message unroutable: exchange=%q routingKey=%q replyText=%q", r.Exchange, r.RoutingKey, r.ReplyText)
+			default:
+				runtime.Gosched()
+			}
+		}
+		return nil
+	}
+}
+
+func (p *Publisher) enableConfirmMode() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.confirmMode {
+		return nil
+	}
+
+	if p.status != StatusConnected {
+		return statusError(p.status)
+	}
+
+	if err := p.channel.Confirm(false); err != nil {
+		return err
+	}
+	p.notifyPublish = make(chan amqp.Confirmation, 1)
+	p.channel.NotifyPublish(p.notifyPublish)
+	go p.dispatchConfirms(p.notifyPublish)
+	p.confirmMode = true
+
+	return nil
+}
+
+// withRetry invokes publish up to opts.MaxPublishAttempts times in total,
+// waiting opts.PublishRetryBackoff between attempts, as long as publish
+// keeps failing with a temporary (reconnecting) error.
+func (p *Publisher) withRetry(ctx context.Context, publish func() error) error {
+	var err error
+	attempts := p.opts.MaxPublishAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = publish()
+		if err == nil {
+			return nil
+		}
+
+		temp, ok := err.(interface{ Temporary() bool })
+		if !ok || !temp.Temporary() || attempt == attempts {
+			return err
+		}
+
+		t := time.NewTimer(p.opts.PublishRetryBackoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-p.done:
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+
+	return err
+}
+
+func statusError(s Status) error {
+	if s == StatusReconnecting {
+		return tempError{err: "temporarily failed to publish: re-connecting with broker"}
+	}
+	return fmt.Errorf("failed to publish: connection is in %q state", s)
+}
+
+// Close closes the connection and channel. A new publisher needs to be
+// created in order to publish again after closing it.
+// It is safe to call this method multiple times and in multiple goroutines.
+//
+// Unlike Consumer.Close, Close takes no context: a Publisher has no
+// background workers to drain (Publish/PublishWithConfirm run, and return,
+// on the caller's own goroutine), so there is nothing for a context to wait
+// on here.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.status != StatusClosed {
+		p.setStatus(StatusClosed, nil)
+		// stop re-connecting/re-opening a channel
+		close(p.done)
+	}
+
+	// nothing to close if we do not have an open connection and channel
+	var errCh error
+	if p.channel != nil && !p.channel.IsClosed() {
+		errCh = p.channel.Close()
+		if errCh != nil {
+			errCh = fmt.Errorf("failed to close channel: %w", errCh)
+		}
+	}
+	var errCon error
+	if p.conn != nil && !p.conn.IsClosed() {
+		errCon = p.conn.Close()
+	}
+	if errCon != nil {
+		return fmt.Errorf("failed to close connection: %w", errCon)
+	}
+
+	return errCh
+}